@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/Bren2010/utahfs"
+)
+
+// BackoffOpts configures a backoffRetry's retry schedule.
+type BackoffOpts struct {
+	// InitialDelay is the delay before the first retry.
+	InitialDelay time.Duration
+	// MaxDelay caps the delay between retries, regardless of how many
+	// attempts have been made.
+	MaxDelay time.Duration
+	// Multiplier is applied to the delay after each failed attempt.
+	Multiplier float64
+	// MaxElapsedTime stops retrying once this much time has passed since
+	// the first attempt. Zero means retry without a time limit.
+	MaxElapsedTime time.Duration
+	// Jitter is the fraction, in [0, 1], of the computed delay that's
+	// randomized. A value of 1 is AWS's "full jitter": the actual delay is
+	// chosen uniformly between zero and the computed delay. A value of 0
+	// disables jitter entirely.
+	Jitter float64
+	// IsRetryable classifies an error returned by the base backend as
+	// retryable or terminal. If nil, every error except
+	// utahfs.ErrObjectNotFound is treated as retryable.
+	IsRetryable func(err error) bool
+}
+
+func (o BackoffOpts) delay(attempt int) time.Duration {
+	d := float64(o.InitialDelay) * math.Pow(o.Multiplier, float64(attempt))
+	if max := float64(o.MaxDelay); d > max {
+		d = max
+	}
+	jittered := d * (1 - o.Jitter + o.Jitter*rand.Float64())
+	return time.Duration(jittered)
+}
+
+func (o BackoffOpts) isRetryable(err error) bool {
+	if err == nil || err == utahfs.ErrObjectNotFound {
+		return false
+	} else if o.IsRetryable != nil {
+		return o.IsRetryable(err)
+	}
+	return true
+}
+
+// ContextObjectStorage is implemented by object storage backends that can
+// thread a context.Context through their operations, for cancellation and
+// deadlines during retries.
+type ContextObjectStorage interface {
+	utahfs.ObjectStorage
+
+	GetCtx(ctx context.Context, key string) ([]byte, error)
+	SetCtx(ctx context.Context, key string, data []byte) error
+	DeleteCtx(ctx context.Context, key string) error
+}
+
+// getCtx dispatches to base's native GetCtx if it implements one, and falls
+// back to a plain Get (ignoring ctx) otherwise.
+func getCtx(base utahfs.ObjectStorage, ctx context.Context, key string) ([]byte, error) {
+	if c, ok := base.(ContextObjectStorage); ok {
+		return c.GetCtx(ctx, key)
+	}
+	return base.Get(key)
+}
+
+// setCtx dispatches to base's native SetCtx if it implements one, and falls
+// back to a plain Set (ignoring ctx) otherwise.
+func setCtx(base utahfs.ObjectStorage, ctx context.Context, key string, data []byte) error {
+	if c, ok := base.(ContextObjectStorage); ok {
+		return c.SetCtx(ctx, key, data)
+	}
+	return base.Set(key, data)
+}
+
+// deleteCtx dispatches to base's native DeleteCtx if it implements one, and
+// falls back to a plain Delete (ignoring ctx) otherwise.
+func deleteCtx(base utahfs.ObjectStorage, ctx context.Context, key string) error {
+	if c, ok := base.(ContextObjectStorage); ok {
+		return c.DeleteCtx(ctx, key)
+	}
+	return base.Delete(key)
+}
+
+type backoffRetry struct {
+	base utahfs.ObjectStorage
+	opts BackoffOpts
+}
+
+// NewBackoffRetry wraps a base object storage backend, and retries failed
+// requests with exponential backoff and jitter, for rate-limited backends
+// like S3 or B2. Setting opts.Jitter to 1 gives the "full jitter" that AWS
+// recommends; 0 disables jitter. It returns a
+// ContextObjectStorage, whose *Ctx methods stop retrying as soon as the
+// given context is canceled.
+func NewBackoffRetry(base utahfs.ObjectStorage, opts BackoffOpts) (ContextObjectStorage, error) {
+	if opts.InitialDelay <= 0 {
+		return nil, errors.New("storage: InitialDelay must be greater than zero")
+	} else if opts.MaxDelay < opts.InitialDelay {
+		return nil, errors.New("storage: MaxDelay must be greater than or equal to InitialDelay")
+	} else if opts.Multiplier < 1 {
+		return nil, errors.New("storage: Multiplier must be greater than or equal to one")
+	} else if opts.Jitter < 0 || opts.Jitter > 1 {
+		return nil, errors.New("storage: Jitter must be between zero and one")
+	}
+	return &backoffRetry{base, opts}, nil
+}
+
+// retry runs fn until it succeeds, fn's error is terminal, ctx is canceled,
+// or opts.MaxElapsedTime has elapsed, sleeping with backoff between
+// attempts.
+func (r *backoffRetry) retry(ctx context.Context, fn func() error) error {
+	start, attempt := time.Now(), 0
+	for {
+		err := fn()
+		if !r.opts.isRetryable(err) {
+			return err
+		} else if r.opts.MaxElapsedTime > 0 && time.Since(start) >= r.opts.MaxElapsedTime {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.opts.delay(attempt)):
+		}
+		attempt++
+	}
+}
+
+// setCollector forwards the collector down to base, so that NewMetrics
+// still reaches a cache tier underneath a backoff retry wrapper, such as in
+// NewMetrics(NewBackoffRetry(NewCache(remote, ...), ...), collector).
+func (r *backoffRetry) setCollector(c Collector) {
+	if cc, ok := r.base.(collectable); ok {
+		cc.setCollector(c)
+	}
+}
+
+func (r *backoffRetry) GetCtx(ctx context.Context, key string) (data []byte, err error) {
+	err = r.retry(ctx, func() error {
+		data, err = r.base.Get(key)
+		return err
+	})
+	return
+}
+
+func (r *backoffRetry) SetCtx(ctx context.Context, key string, data []byte) error {
+	return r.retry(ctx, func() error { return r.base.Set(key, data) })
+}
+
+func (r *backoffRetry) DeleteCtx(ctx context.Context, key string) error {
+	return r.retry(ctx, func() error { return r.base.Delete(key) })
+}
+
+func (r *backoffRetry) Get(key string) ([]byte, error) {
+	return r.GetCtx(context.Background(), key)
+}
+
+func (r *backoffRetry) Set(key string, data []byte) error {
+	return r.SetCtx(context.Background(), key, data)
+}
+
+func (r *backoffRetry) Delete(key string) error {
+	return r.DeleteCtx(context.Background(), key)
+}
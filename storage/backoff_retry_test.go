@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Bren2010/utahfs"
+)
+
+// flakyStorage fails Get a fixed number of times before succeeding, to
+// exercise backoffRetry's retry loop.
+type flakyStorage struct {
+	failures int
+	calls    int
+}
+
+func (f *flakyStorage) Get(key string) ([]byte, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, errors.New("flaky: transient failure")
+	}
+	return []byte("ok"), nil
+}
+
+func (f *flakyStorage) Set(key string, data []byte) error { return nil }
+func (f *flakyStorage) Delete(key string) error           { return nil }
+
+// alwaysFailStorage fails every call, to exercise cancellation and
+// MaxElapsedTime.
+type alwaysFailStorage struct{}
+
+func (alwaysFailStorage) Get(key string) ([]byte, error) { return nil, errors.New("always fails") }
+func (alwaysFailStorage) Set(key string, data []byte) error { return errors.New("always fails") }
+func (alwaysFailStorage) Delete(key string) error            { return errors.New("always fails") }
+
+func noJitterOpts() BackoffOpts {
+	return BackoffOpts{
+		InitialDelay: time.Millisecond,
+		MaxDelay:     100 * time.Millisecond,
+		Multiplier:   2,
+		Jitter:       0,
+	}
+}
+
+func TestNewBackoffRetryValidatesOpts(t *testing.T) {
+	base := noJitterOpts()
+	tests := []BackoffOpts{
+		{InitialDelay: 0, MaxDelay: base.MaxDelay, Multiplier: base.Multiplier},
+		{InitialDelay: base.InitialDelay, MaxDelay: 0, Multiplier: base.Multiplier},
+		{InitialDelay: base.InitialDelay, MaxDelay: base.MaxDelay, Multiplier: 0.5},
+		{InitialDelay: base.InitialDelay, MaxDelay: base.MaxDelay, Multiplier: base.Multiplier, Jitter: -1},
+		{InitialDelay: base.InitialDelay, MaxDelay: base.MaxDelay, Multiplier: base.Multiplier, Jitter: 2},
+	}
+	for i, opts := range tests {
+		if _, err := NewBackoffRetry(NewMemory(), opts); err == nil {
+			t.Errorf("case %d: expected a validation error, got none", i)
+		}
+	}
+}
+
+func TestBackoffOptsDelay(t *testing.T) {
+	opts := BackoffOpts{InitialDelay: 10 * time.Millisecond, MaxDelay: 100 * time.Millisecond, Multiplier: 2, Jitter: 0}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{1, 20 * time.Millisecond},
+		{2, 40 * time.Millisecond},
+		{3, 80 * time.Millisecond},
+		{4, 100 * time.Millisecond}, // Capped at MaxDelay.
+	}
+	for _, tt := range tests {
+		if got := opts.delay(tt.attempt); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffOptsDelayFullJitterBounded(t *testing.T) {
+	opts := BackoffOpts{InitialDelay: 10 * time.Millisecond, MaxDelay: 100 * time.Millisecond, Multiplier: 2, Jitter: 1}
+	for i := 0; i < 100; i++ {
+		d := opts.delay(1)
+		if d < 0 || d > 20*time.Millisecond {
+			t.Fatalf("delay(1) = %v, want within [0, 20ms] full-jitter bounds", d)
+		}
+	}
+}
+
+func TestBackoffOptsIsRetryable(t *testing.T) {
+	opts := BackoffOpts{}
+	if opts.isRetryable(nil) {
+		t.Error("nil error should not be retryable")
+	}
+	if opts.isRetryable(utahfs.ErrObjectNotFound) {
+		t.Error("ErrObjectNotFound should not be retryable")
+	}
+	if !opts.isRetryable(errors.New("boom")) {
+		t.Error("a generic error should be retryable by default")
+	}
+
+	terminal := errors.New("terminal")
+	opts.IsRetryable = func(err error) bool { return err != terminal }
+	if opts.isRetryable(terminal) {
+		t.Error("an error classified by IsRetryable as terminal should not be retryable")
+	}
+	if !opts.isRetryable(errors.New("other")) {
+		t.Error("an error classified by IsRetryable as retryable should be retryable")
+	}
+}
+
+func TestBackoffRetrySucceedsAfterTransientFailures(t *testing.T) {
+	base := &flakyStorage{failures: 2}
+	store, err := NewBackoffRetry(base, noJitterOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := store.Get("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if string(data) != "ok" {
+		t.Fatalf("got %q, want %q", data, "ok")
+	}
+	if base.calls != 3 {
+		t.Fatalf("base.calls = %d, want 3", base.calls)
+	}
+}
+
+func TestBackoffRetryStopsOnContextCancel(t *testing.T) {
+	store, err := NewBackoffRetry(alwaysFailStorage{}, BackoffOpts{
+		InitialDelay: 50 * time.Millisecond,
+		MaxDelay:     50 * time.Millisecond,
+		Multiplier:   1,
+		Jitter:       0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := store.GetCtx(ctx, "x"); err != context.DeadlineExceeded {
+		t.Fatalf("GetCtx error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestBackoffRetryStopsOnNotFound(t *testing.T) {
+	notFound := &notFoundStorage{}
+	store, err := NewBackoffRetry(notFound, noJitterOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Get("missing"); err != utahfs.ErrObjectNotFound {
+		t.Fatalf("Get error = %v, want utahfs.ErrObjectNotFound", err)
+	}
+	if notFound.calls != 1 {
+		t.Fatalf("calls = %d, want 1: ErrObjectNotFound should not be retried", notFound.calls)
+	}
+}
+
+type notFoundStorage struct{ calls int }
+
+func (s *notFoundStorage) Get(key string) ([]byte, error) {
+	s.calls++
+	return nil, utahfs.ErrObjectNotFound
+}
+func (s *notFoundStorage) Set(key string, data []byte) error { return nil }
+func (s *notFoundStorage) Delete(key string) error            { return nil }
@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/Bren2010/utahfs"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// SyncMode controls how aggressively a disk cache flushes writes to stable
+// storage.
+type SyncMode int
+
+const (
+	// SyncBestEffort lets the OS decide when dirty pages are written back,
+	// which is faster but can lose recently-cached entries on a crash.
+	SyncBestEffort SyncMode = iota
+	// SyncAlways calls fsync after every write, trading throughput for the
+	// guarantee that a cached entry survives a crash once Set returns.
+	SyncAlways
+)
+
+// DiskCacheStats is a snapshot of a disk cache's runtime counters, returned
+// by diskCache.Stats.
+type DiskCacheStats struct {
+	Hits, Misses, Evictions uint64
+	BytesOnDisk             int64
+}
+
+type diskEntry struct {
+	size int64
+}
+
+type diskCache struct {
+	base     utahfs.ObjectStorage
+	dir      string
+	maxBytes int64
+	sync     SyncMode
+
+	mu                      sync.Mutex
+	lru                     *simplelru.LRU
+	size                    int64
+	hits, misses, evictions uint64
+	collector               Collector
+}
+
+// NewDiskCache wraps a base object storage backend with a cache tier that
+// persists entries to the local filesystem under dir, so that a restart
+// doesn't require re-downloading every block from the remote store. It's
+// meant to be stacked under NewCache, for a fast RAM + large disk cache in
+// front of a remote backend like S3 or GCS.
+func NewDiskCache(base utahfs.ObjectStorage, dir string, maxBytes int64, sync SyncMode) (utahfs.ObjectStorage, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("storage: maxBytes must be greater than zero")
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	dc := &diskCache{base: base, dir: dir, maxBytes: maxBytes, sync: sync}
+	lru, err := simplelru.NewLRU(math.MaxInt32, dc.onEvict)
+	if err != nil {
+		return nil, err
+	}
+	dc.lru = lru
+
+	if err := dc.reload(); err != nil {
+		return nil, err
+	}
+	return dc, nil
+}
+
+// reload walks dir and rebuilds the in-memory LRU index from whatever
+// entries are already on disk, oldest-first so that the final LRU order
+// reflects each file's last-modified time.
+func (c *diskCache) reload() error {
+	type found struct {
+		key   string
+		size  int64
+		mtime int64
+	}
+	var entries []found
+
+	err := filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		key, decErr := decodeCacheKey(filepath.Base(path))
+		if decErr != nil {
+			// Not a cache entry we recognize — most likely a temp file left
+			// behind by add() after a crash between the write and the
+			// rename. The cache dir is ours alone, so it's safe to reclaim.
+			os.Remove(path)
+			return nil
+		}
+		entries = append(entries, found{key, info.Size(), info.ModTime().UnixNano()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].mtime < entries[j].mtime })
+
+	for _, e := range entries {
+		c.lru.Add(e.key, diskEntry{e.size})
+		c.size += e.size
+	}
+	for c.size > c.maxBytes {
+		c.lru.RemoveOldest()
+		c.evictions++
+	}
+	return nil
+}
+
+func (c *diskCache) onEvict(key, value interface{}) {
+	c.size -= value.(diskEntry).size
+	os.Remove(c.path(key.(string)))
+}
+
+// setCollector lets NewMetrics push a Collector down into this tier, so
+// diskCache's existing hit/miss counters are also reported to it.
+func (c *diskCache) setCollector(collector Collector) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.collector = collector
+}
+
+func (c *diskCache) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	_, ok := c.lru.Get(key)
+	c.mu.Unlock()
+
+	if ok {
+		data, err := ioutil.ReadFile(c.path(key))
+		if err == nil {
+			c.mu.Lock()
+			c.hits++
+			collector := c.collector
+			c.mu.Unlock()
+			if collector != nil {
+				collector.IncCacheResult(true)
+			}
+			return data, nil
+		}
+		// The file vanished out from under the index; fall through and
+		// re-populate it from base.
+	}
+
+	c.mu.Lock()
+	c.misses++
+	collector := c.collector
+	c.mu.Unlock()
+	if collector != nil {
+		collector.IncCacheResult(false)
+	}
+
+	data, err := c.base.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	c.add(key, data)
+	return data, nil
+}
+
+func (c *diskCache) Set(key string, data []byte) error {
+	if err := c.base.Set(key, data); err != nil {
+		return err
+	}
+	c.add(key, data)
+	return nil
+}
+
+func (c *diskCache) Delete(key string) error {
+	c.mu.Lock()
+	c.lru.Remove(key)
+	c.mu.Unlock()
+	return c.base.Delete(key)
+}
+
+// add atomically writes data to the on-disk tier, then records it in the
+// LRU index and evicts the least-recently-used entries until the cache's
+// on-disk footprint is back under maxBytes.
+func (c *diskCache) add(key string, data []byte) {
+	subdir := filepath.Dir(c.path(key))
+	if err := os.MkdirAll(subdir, 0755); err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(subdir, ".tmp-")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name()) // No-op once the rename below succeeds.
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if c.sync == SyncAlways {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		return
+	}
+	if c.sync == SyncAlways {
+		// The rename itself isn't durable until the directory entry that
+		// points at it is fsynced too.
+		if d, err := os.Open(subdir); err == nil {
+			d.Sync()
+			d.Close()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Don't route this through Remove: its onEvict callback deletes the
+	// on-disk file for the key, which would delete the file we just wrote.
+	// Peek the old size instead, so Add below updates the existing entry
+	// in place without evicting it.
+	if old, ok := c.lru.Peek(key); ok {
+		c.size -= old.(diskEntry).size
+	}
+	c.lru.Add(key, diskEntry{int64(len(data))})
+	c.size += int64(len(data))
+	for c.size > c.maxBytes {
+		c.lru.RemoveOldest()
+		c.evictions++
+	}
+}
+
+// Stats returns a snapshot of the cache's hit, miss, eviction, and
+// on-disk-size counters.
+func (c *diskCache) Stats() DiskCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return DiskCacheStats{
+		Hits:        c.hits,
+		Misses:      c.misses,
+		Evictions:   c.evictions,
+		BytesOnDisk: c.size,
+	}
+}
+
+// path returns the fan-out path that key is stored at on disk: dir, then a
+// subdir named after the first two hex characters of the key, then the
+// fully hex-encoded key.
+func (c *diskCache) path(key string) string {
+	enc := encodeCacheKey(key)
+	sub := enc
+	if len(sub) > 2 {
+		sub = sub[:2]
+	}
+	return filepath.Join(c.dir, sub, enc)
+}
+
+func encodeCacheKey(key string) string {
+	return hex.EncodeToString([]byte(key))
+}
+
+func decodeCacheKey(filename string) (string, error) {
+	data, err := hex.DecodeString(filename)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskCacheEvictsUnderMaxBytes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "utahfs-diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := NewMemory()
+	if err := base.Set("a", []byte("aaaaaaaaaa")); err != nil {
+		t.Fatal(err)
+	}
+	if err := base.Set("b", []byte("bbbbbbbbbb")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Budget fits only one of the two 10-byte values.
+	store, err := NewDiskCache(base, dir, 10, SyncBestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := store.(*diskCache)
+
+	if _, err := store.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := dc.Stats()
+	if stats.BytesOnDisk > 10 {
+		t.Fatalf("BytesOnDisk = %d, want <= 10", stats.BytesOnDisk)
+	}
+	if stats.Evictions == 0 {
+		t.Fatal("expected at least one eviction once the budget was exceeded")
+	}
+}
+
+func TestDiskCacheSurvivesRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "utahfs-diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := NewMemory()
+	if err := base.Set("a", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewDiskCache(base, dir, 1<<20, SyncBestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a restart: a fresh cache over the same dir, with an empty
+	// base, should still serve "a" from what's on disk.
+	store2, err := NewDiskCache(NewMemory(), dir, 1<<20, SyncBestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := store2.Get("a")
+	if err != nil {
+		t.Fatalf("expected entry to survive restart: %v", err)
+	} else if string(data) != "hello" {
+		t.Fatalf("got %q, want %q", data, "hello")
+	}
+	if store2.(*diskCache).Stats().Hits != 1 {
+		t.Fatalf("expected the reloaded entry to be served as a hit")
+	}
+}
+
+func TestDiskCacheReloadRemovesStrayTempFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "utahfs-diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	subdir := filepath.Join(dir, "ab")
+	if err := os.MkdirAll(subdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	strayPath := filepath.Join(subdir, ".tmp-stray")
+	if err := ioutil.WriteFile(strayPath, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewDiskCache(NewMemory(), dir, 1<<20, SyncBestEffort); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(strayPath); !os.IsNotExist(err) {
+		t.Fatalf("expected stray temp file to be removed on reload, stat err = %v", err)
+	}
+}
+
+func TestDiskCacheDeleteRemovesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "utahfs-diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := NewMemory()
+	if err := base.Set("a", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewDiskCache(base, dir, 1<<20, SyncBestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := store.(*diskCache)
+
+	if _, err := store.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dc.path("a")); !os.IsNotExist(err) {
+		t.Fatalf("expected on-disk file to be removed after Delete, stat err = %v", err)
+	}
+}
+
+func TestDiskCacheSurvivesOverwrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "utahfs-diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := NewMemory()
+	store, err := NewDiskCache(base, dir, 1<<20, SyncBestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := store.(*diskCache)
+
+	if err := store.Set("a", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("a", []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(dc.path("a")); err != nil {
+		t.Fatalf("expected on-disk file to survive an overwrite, stat err = %v", err)
+	}
+	data, err := ioutil.ReadFile(dc.path("a"))
+	if err != nil {
+		t.Fatal(err)
+	} else if string(data) != "v2" {
+		t.Fatalf("on-disk file = %q, want %q", data, "v2")
+	}
+
+	data, err = store.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	} else if string(data) != "v2" {
+		t.Fatalf("Get(\"a\") = %q, want %q", data, "v2")
+	}
+	if dc.Stats().Misses != 0 {
+		t.Fatalf("Misses = %d, want 0: overwrite shouldn't have deleted the cached file", dc.Stats().Misses)
+	}
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/Bren2010/utahfs"
+)
+
+// Collector receives instrumentation events from NewMetrics and any cache
+// tiers it's stacked over. A default Prometheus-backed implementation lives
+// in the storage/metrics sub-package, so that consumers who don't want
+// Prometheus don't pay for the dependency.
+type Collector interface {
+	// ObserveLatency records how long an operation (e.g. "get", "set",
+	// "delete") took.
+	ObserveLatency(op string, d time.Duration)
+	// IncError records an operation failing, broken down by reason:
+	// "not_found", "transient", or "terminal".
+	IncError(op, reason string)
+	// IncBytes records the size of the payload an operation transferred.
+	IncBytes(op string, n int)
+	// IncCacheResult records a cache lookup hitting or missing, for
+	// backends stacked under NewMetrics that support it.
+	IncCacheResult(hit bool)
+}
+
+// Reasons used with Collector.IncError.
+const (
+	ReasonNotFound  = "not_found"
+	ReasonTransient = "transient"
+	ReasonTerminal  = "terminal"
+)
+
+// temporary is implemented by errors that know whether they're transient,
+// following the convention used by net.Error and similar.
+type temporary interface {
+	Temporary() bool
+}
+
+func classifyReason(err error) string {
+	if err == utahfs.ErrObjectNotFound {
+		return ReasonNotFound
+	} else if t, ok := err.(temporary); ok && t.Temporary() {
+		return ReasonTransient
+	}
+	return ReasonTerminal
+}
+
+// collectable is implemented by cache tiers that can report their hit/miss
+// results to a Collector once one is pushed down into them by NewMetrics.
+// retry and backoffRetry also implement it, by forwarding to their own
+// base, so the collector still reaches a cache tier stacked underneath one
+// of them (e.g. NewMetrics(NewBackoffRetry(NewCache(remote, ...), ...), c)).
+type collectable interface {
+	setCollector(c Collector)
+}
+
+type metrics struct {
+	base utahfs.ObjectStorage
+	c    Collector
+}
+
+// NewMetrics wraps a base object storage backend with instrumentation,
+// recording per-operation counters, error counts by reason, and histograms
+// of latency and payload size via c. If base is a cache tier from this
+// package (such as one returned by NewCache, NewSizeCache, or
+// NewDiskCache), its hit/miss results are also reported to c — including
+// when that cache tier sits underneath a NewRetry or NewBackoffRetry
+// wrapper, as in NewMetrics(NewBackoffRetry(NewCache(remote, ...), ...), c).
+// The returned value also implements ContextObjectStorage and
+// StreamingObjectStorage, so wrapping a backoffRetry or a streaming-capable
+// cache with NewMetrics doesn't strip their GetCtx/SetCtx/DeleteCtx or
+// GetRange/SetStream methods from callers that type-assert for them; those
+// calls fall back to the plain, non-context/non-streaming path when base
+// doesn't support them natively.
+func NewMetrics(base utahfs.ObjectStorage, c Collector) utahfs.ObjectStorage {
+	if cc, ok := base.(collectable); ok {
+		cc.setCollector(c)
+	}
+	return &metrics{base, c}
+}
+
+func (m *metrics) GetCtx(ctx context.Context, key string) ([]byte, error) {
+	start := time.Now()
+	data, err := getCtx(m.base, ctx, key)
+	m.c.ObserveLatency("get", time.Since(start))
+	if err != nil {
+		m.c.IncError("get", classifyReason(err))
+		return nil, err
+	}
+	m.c.IncBytes("get", len(data))
+	return data, nil
+}
+
+func (m *metrics) SetCtx(ctx context.Context, key string, data []byte) error {
+	start := time.Now()
+	err := setCtx(m.base, ctx, key, data)
+	m.c.ObserveLatency("set", time.Since(start))
+	if err != nil {
+		m.c.IncError("set", classifyReason(err))
+		return err
+	}
+	m.c.IncBytes("set", len(data))
+	return nil
+}
+
+func (m *metrics) DeleteCtx(ctx context.Context, key string) error {
+	start := time.Now()
+	err := deleteCtx(m.base, ctx, key)
+	m.c.ObserveLatency("delete", time.Since(start))
+	if err != nil {
+		m.c.IncError("delete", classifyReason(err))
+	}
+	return err
+}
+
+func (m *metrics) Get(key string) ([]byte, error) { return m.GetCtx(context.Background(), key) }
+
+func (m *metrics) Set(key string, data []byte) error {
+	return m.SetCtx(context.Background(), key, data)
+}
+
+func (m *metrics) Delete(key string) error { return m.DeleteCtx(context.Background(), key) }
+
+func (m *metrics) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	start := time.Now()
+	rc, err := getRange(m.base, key, offset, length)
+	m.c.ObserveLatency("get_range", time.Since(start))
+	if err != nil {
+		m.c.IncError("get_range", classifyReason(err))
+		return nil, err
+	}
+	return rc, nil
+}
+
+func (m *metrics) SetStream(key string, r io.Reader, size int64) error {
+	start := time.Now()
+	err := setStream(m.base, key, r, size)
+	m.c.ObserveLatency("set_stream", time.Since(start))
+	if err != nil {
+		m.c.IncError("set_stream", classifyReason(err))
+		return err
+	}
+	m.c.IncBytes("set_stream", int(size))
+	return nil
+}
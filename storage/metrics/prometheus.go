@@ -0,0 +1,88 @@
+// Package metrics implements a Prometheus-backed storage.Collector, kept
+// separate from the storage package so that consumers who don't use
+// Prometheus don't pay for the dependency.
+package metrics
+
+import (
+	"time"
+
+	"github.com/Bren2010/utahfs/storage"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusCollector is a storage.Collector that reports instrumentation
+// events as Prometheus metrics.
+type PrometheusCollector struct {
+	latency      *prometheus.HistogramVec
+	errors       *prometheus.CounterVec
+	bytes        *prometheus.CounterVec
+	cacheResults *prometheus.CounterVec
+}
+
+// NewPrometheusCollector creates a PrometheusCollector and registers its
+// metrics with reg. If reg is nil, prometheus.DefaultRegisterer is used.
+// Pass a fresh *prometheus.Registry (rather than nil) when a process may
+// construct more than one collector, such as in tests or when mounting
+// more than one utahfs volume, since registering the same metric with the
+// default registerer twice fails, and NewPrometheusCollector then returns
+// that error.
+func NewPrometheusCollector(reg prometheus.Registerer) (*PrometheusCollector, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	c := &PrometheusCollector{
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "utahfs",
+			Subsystem: "storage",
+			Name:      "operation_latency_seconds",
+			Help:      "Latency of object storage operations.",
+		}, []string{"op"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "utahfs",
+			Subsystem: "storage",
+			Name:      "errors_total",
+			Help:      "Count of object storage errors, by operation and reason.",
+		}, []string{"op", "reason"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "utahfs",
+			Subsystem: "storage",
+			Name:      "bytes_total",
+			Help:      "Count of bytes transferred by object storage operations.",
+		}, []string{"op"}),
+		cacheResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "utahfs",
+			Subsystem: "storage",
+			Name:      "cache_results_total",
+			Help:      "Count of cache lookups, by hit or miss.",
+		}, []string{"result"}),
+	}
+	for _, coll := range []prometheus.Collector{c.latency, c.errors, c.bytes, c.cacheResults} {
+		if err := reg.Register(coll); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *PrometheusCollector) ObserveLatency(op string, d time.Duration) {
+	c.latency.WithLabelValues(op).Observe(d.Seconds())
+}
+
+func (c *PrometheusCollector) IncError(op, reason string) {
+	c.errors.WithLabelValues(op, reason).Inc()
+}
+
+func (c *PrometheusCollector) IncBytes(op string, n int) {
+	c.bytes.WithLabelValues(op).Add(float64(n))
+}
+
+func (c *PrometheusCollector) IncCacheResult(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	c.cacheResults.WithLabelValues(result).Inc()
+}
+
+var _ storage.Collector = (*PrometheusCollector)(nil)
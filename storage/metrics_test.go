@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeCollector records the events it receives, for assertions in tests.
+type fakeCollector struct {
+	cacheResults []bool
+}
+
+func (f *fakeCollector) ObserveLatency(op string, d time.Duration) {}
+func (f *fakeCollector) IncError(op, reason string)                {}
+func (f *fakeCollector) IncBytes(op string, n int)                 {}
+func (f *fakeCollector) IncCacheResult(hit bool) {
+	f.cacheResults = append(f.cacheResults, hit)
+}
+
+func TestNewMetricsForwardsCollectorThroughRetry(t *testing.T) {
+	base := NewMemory()
+	if err := base.Set("a", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := NewCache(base, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retried, err := NewRetry(cached, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc := &fakeCollector{}
+	store := NewMetrics(retried, fc)
+
+	if _, err := store.Get("a"); err != nil { // Miss: populates the cache.
+		t.Fatal(err)
+	}
+	if _, err := store.Get("a"); err != nil { // Hit.
+		t.Fatal(err)
+	}
+
+	if len(fc.cacheResults) != 2 {
+		t.Fatalf("got %d cache results, want 2: collector wasn't forwarded through NewRetry", len(fc.cacheResults))
+	}
+	if fc.cacheResults[0] || !fc.cacheResults[1] {
+		t.Fatalf("cacheResults = %v, want [false, true]", fc.cacheResults)
+	}
+}
+
+func TestNewMetricsForwardsCollectorThroughBackoffRetry(t *testing.T) {
+	base := NewMemory()
+	if err := base.Set("a", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := NewSizeCache(base, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retried, err := NewBackoffRetry(cached, noJitterOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc := &fakeCollector{}
+	store := NewMetrics(retried, fc)
+
+	if _, err := store.Get("a"); err != nil { // Miss.
+		t.Fatal(err)
+	}
+	if _, err := store.Get("a"); err != nil { // Hit.
+		t.Fatal(err)
+	}
+
+	if len(fc.cacheResults) != 2 {
+		t.Fatalf("got %d cache results, want 2: collector wasn't forwarded through NewBackoffRetry", len(fc.cacheResults))
+	}
+	if fc.cacheResults[0] || !fc.cacheResults[1] {
+		t.Fatalf("cacheResults = %v, want [false, true]", fc.cacheResults)
+	}
+}
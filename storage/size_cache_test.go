@@ -0,0 +1,104 @@
+package storage
+
+import "testing"
+
+func TestNewSizeCacheValidatesMaxBytes(t *testing.T) {
+	if _, err := NewSizeCache(NewMemory(), 0); err == nil {
+		t.Fatal("expected error for non-positive maxBytes")
+	}
+	if _, err := NewSizeCache(NewMemory(), -1); err == nil {
+		t.Fatal("expected error for negative maxBytes")
+	}
+}
+
+func TestSizeCacheEvictsUnderMaxBytes(t *testing.T) {
+	base := NewMemory()
+	if err := base.Set("a", []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if err := base.Set("b", []byte("9876543210")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Budget fits one 10-byte value (plus overhead), but not two.
+	store, err := NewSizeCache(base, 10+cacheEntryOverhead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := store.(*sizeCache)
+
+	if _, err := store.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if sc.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", sc.Len())
+	}
+
+	if _, err := store.Get("b"); err != nil {
+		t.Fatal(err)
+	}
+	if sc.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1 after eviction", sc.Len())
+	}
+	if sc.Size() > 10+cacheEntryOverhead {
+		t.Fatalf("Size() = %d, want <= %d", sc.Size(), 10+cacheEntryOverhead)
+	}
+	if _, ok := sc.lru.Get("a"); ok {
+		t.Fatal("expected \"a\" to have been evicted to make room for \"b\"")
+	}
+}
+
+func TestSizeCacheRejectsOversizedValue(t *testing.T) {
+	base := NewMemory()
+	big := make([]byte, 100)
+	if err := base.Set("big", big); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewSizeCache(base, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := store.(*sizeCache)
+
+	data, err := store.Get("big")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(data) != len(big) {
+		t.Fatalf("got %d bytes, want %d", len(data), len(big))
+	}
+	if sc.Len() != 0 {
+		t.Fatalf("Len() = %d, want 0: oversized value should not be cached", sc.Len())
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"256MB", 256 << 20, false},
+		{"1GB", 1 << 30, false},
+		{"10KB", 10 << 10, false},
+		{"1TB", 1 << 40, false},
+		{"42", 42, false},
+		{"  128MB  ", 128 << 20, false},
+		{"bogus", 0, true},
+		{"-5MB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseSize(%q): expected error, got %d", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSize(%q): unexpected error: %v", tt.in, err)
+		} else if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
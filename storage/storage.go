@@ -3,10 +3,16 @@ package storage
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/Bren2010/utahfs"
 
 	"github.com/hashicorp/golang-lru"
+	"github.com/hashicorp/golang-lru/simplelru"
 )
 
 func dup(in []byte) []byte {
@@ -58,6 +64,15 @@ func NewRetry(base utahfs.ObjectStorage, attempts int) (utahfs.ObjectStorage, er
 	return &retry{base, attempts}, nil
 }
 
+// setCollector forwards the collector down to base, so that NewMetrics
+// still reaches a cache tier underneath a retry wrapper, such as in
+// NewMetrics(NewRetry(NewCache(remote, ...), ...), collector).
+func (r *retry) setCollector(c Collector) {
+	if cc, ok := r.base.(collectable); ok {
+		cc.setCollector(c)
+	}
+}
+
 func (r *retry) Get(key string) (data []byte, err error) {
 	for i := 0; i < r.attempts; i++ {
 		data, err = r.base.Get(key)
@@ -92,8 +107,9 @@ func (r *retry) Delete(key string) (err error) {
 }
 
 type cache struct {
-	base  utahfs.ObjectStorage
-	cache *lru.Cache
+	base      utahfs.ObjectStorage
+	cache     *lru.Cache
+	collector Collector
 }
 
 // NewCache wraps a base object storage backend with an LRU cache of the
@@ -103,11 +119,16 @@ func NewCache(base utahfs.ObjectStorage, size int) (utahfs.ObjectStorage, error)
 	if err != nil {
 		return nil, err
 	}
-	return &cache{base, c}, nil
+	return &cache{base, c, nil}, nil
 }
 
+func (c *cache) setCollector(collector Collector) { c.collector = collector }
+
 func (c *cache) Get(key string) ([]byte, error) {
 	val, ok := c.cache.Get(key)
+	if c.collector != nil {
+		c.collector.IncCacheResult(ok)
+	}
 	if ok {
 		return dup(val.([]byte)), nil
 	}
@@ -131,4 +152,161 @@ func (c *cache) Set(key string, data []byte) error {
 func (c *cache) Delete(key string) error {
 	c.cache.Remove(key)
 	return c.base.Delete(key)
+}
+
+// cacheEntryOverhead is a fixed per-entry cost added on top of a value's
+// length, to account for the key and bookkeeping kept alongside it.
+const cacheEntryOverhead = 64
+
+type sizeCache struct {
+	base utahfs.ObjectStorage
+
+	mu        sync.Mutex
+	lru       *simplelru.LRU
+	size      int64
+	maxBytes  int64
+	collector Collector
+}
+
+// NewSizeCache wraps a base object storage backend with an LRU cache that's
+// bounded by the total byte size of its entries, rather than by their count.
+// This is a better fit than NewCache when cached values vary widely in size.
+func NewSizeCache(base utahfs.ObjectStorage, maxBytes int64) (utahfs.ObjectStorage, error) {
+	if maxBytes <= 0 {
+		return nil, errors.New("storage: maxBytes must be greater than zero")
+	}
+	sc := &sizeCache{base: base, maxBytes: maxBytes}
+	lru, err := simplelru.NewLRU(math.MaxInt32, sc.onEvict)
+	if err != nil {
+		return nil, err
+	}
+	sc.lru = lru
+	return sc, nil
+}
+
+// onEvict is called by the underlying simplelru whenever an entry is
+// removed, whether by us or by simplelru itself, so that size stays in sync.
+func (c *sizeCache) onEvict(_, value interface{}) {
+	c.size -= int64(len(value.([]byte))) + cacheEntryOverhead
+}
+
+func (c *sizeCache) setCollector(collector Collector) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.collector = collector
+}
+
+func (c *sizeCache) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	val, ok := c.lru.Get(key)
+	if c.collector != nil {
+		c.collector.IncCacheResult(ok)
+	}
+	c.mu.Unlock()
+	if ok {
+		return dup(val.([]byte)), nil
+	}
+
+	data, err := c.base.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	c.add(key, dup(data))
+	return data, nil
+}
+
+func (c *sizeCache) Set(key string, data []byte) error {
+	c.mu.Lock()
+	c.lru.Remove(key)
+	c.mu.Unlock()
+
+	if err := c.base.Set(key, data); err != nil {
+		return err
+	}
+	c.add(key, dup(data))
+	return nil
+}
+
+func (c *sizeCache) Delete(key string) error {
+	c.mu.Lock()
+	c.lru.Remove(key)
+	c.mu.Unlock()
+	return c.base.Delete(key)
+}
+
+// add inserts data into the cache, evicting the least-recently-used entries
+// until the cache's total size fits under maxBytes. Values that can't fit
+// under the budget on their own are rejected rather than cached.
+func (c *sizeCache) add(key string, data []byte) {
+	entrySize := int64(len(data)) + cacheEntryOverhead
+	if entrySize > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Add updates an existing key in place without calling onEvict, so drop
+	// the old entry's size here to keep size in sync.
+	if old, ok := c.lru.Peek(key); ok {
+		c.size -= int64(len(old.([]byte))) + cacheEntryOverhead
+	}
+	c.lru.Add(key, data)
+	c.size += entrySize
+	for c.size > c.maxBytes {
+		c.lru.RemoveOldest()
+	}
+}
+
+// Len returns the number of entries currently held in the cache.
+func (c *sizeCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Size returns the current byte occupancy of the cache, including the
+// per-entry overhead.
+func (c *sizeCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// sizeSuffixes maps human-readable byte-size suffixes to their multiplier,
+// for parsing CLI flags like "256MB".
+var sizeSuffixes = []struct {
+	suffix string
+	mult   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a human-readable byte size, such as "256MB" or "1GB", as
+// used by CLI flags that configure NewSizeCache and NewDiskCache.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	for _, suf := range sizeSuffixes {
+		if !strings.HasSuffix(strings.ToUpper(s), suf.suffix) {
+			continue
+		}
+		numPart := s[:len(s)-len(suf.suffix)]
+		val, err := strconv.ParseFloat(strings.TrimSpace(numPart), 64)
+		if err != nil {
+			return 0, fmt.Errorf("storage: invalid size %q: %v", s, err)
+		} else if val < 0 {
+			return 0, fmt.Errorf("storage: invalid size %q: must not be negative", s)
+		}
+		return int64(val * float64(suf.mult)), nil
+	}
+
+	val, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("storage: invalid size %q: %v", s, err)
+	}
+	return val, nil
 }
\ No newline at end of file
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/Bren2010/utahfs"
+)
+
+// StreamingObjectStorage is implemented by object storage backends that can
+// serve partial reads and accept streamed writes natively, rather than
+// always buffering a whole object into memory. This matters for backends
+// like S3 or GCS, where FUSE often only needs part of a large block, or a
+// cache warmup wants to stream instead of buffering.
+type StreamingObjectStorage interface {
+	utahfs.ObjectStorage
+
+	GetRange(key string, offset, length int64) (io.ReadCloser, error)
+	SetStream(key string, r io.Reader, size int64) error
+}
+
+// LimitReadCloser wraps a ReadCloser so that at most n bytes can be read
+// from it, so a caller who asked for a range can't be handed more than it
+// requested.
+type LimitReadCloser struct {
+	io.Reader
+	c io.Closer
+}
+
+// NewLimitReadCloser returns an io.ReadCloser that reads at most n bytes
+// from rc, and closes rc when it's closed.
+func NewLimitReadCloser(rc io.ReadCloser, n int64) *LimitReadCloser {
+	return &LimitReadCloser{io.LimitReader(rc, n), rc}
+}
+
+func (l *LimitReadCloser) Close() error { return l.c.Close() }
+
+// GetRange is the default adapter for backends that don't natively support
+// range reads: it fetches the whole object with Get and slices out the
+// requested range in memory.
+func GetRange(base utahfs.ObjectStorage, key string, offset, length int64) (io.ReadCloser, error) {
+	data, err := base.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(data, offset, length)
+}
+
+// SetStream is the default adapter for backends that don't natively support
+// streamed writes: it buffers r into memory and calls Set.
+func SetStream(base utahfs.ObjectStorage, key string, r io.Reader, size int64) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+	return base.Set(key, data)
+}
+
+// getRange dispatches to base's native GetRange if it implements one, and
+// falls back to the default adapter otherwise.
+func getRange(base utahfs.ObjectStorage, key string, offset, length int64) (io.ReadCloser, error) {
+	if s, ok := base.(StreamingObjectStorage); ok {
+		return s.GetRange(key, offset, length)
+	}
+	return GetRange(base, key, offset, length)
+}
+
+// setStream dispatches to base's native SetStream if it implements one, and
+// falls back to the default adapter otherwise.
+func setStream(base utahfs.ObjectStorage, key string, r io.Reader, size int64) error {
+	if s, ok := base.(StreamingObjectStorage); ok {
+		return s.SetStream(key, r, size)
+	}
+	return SetStream(base, key, r, size)
+}
+
+// sliceRange returns a reader over data[offset:offset+length]. A negative
+// length means "the rest of the object".
+func sliceRange(data []byte, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 || offset > int64(len(data)) {
+		return nil, fmt.Errorf("storage: range offset %d is out of bounds for a %d-byte object", offset, len(data))
+	}
+	end := offset + length
+	if length < 0 || end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	return ioutil.NopCloser(bytes.NewReader(data[offset:end])), nil
+}
+
+// rangeCovered reports whether a dataLen-byte in-memory copy of an object
+// fully covers the requested [offset, offset+length) range.
+func rangeCovered(dataLen, offset, length int64) bool {
+	if offset < 0 || offset > dataLen {
+		return false
+	} else if length < 0 {
+		return true
+	}
+	return offset+length <= dataLen
+}
+
+func (r *retry) GetRange(key string, offset, length int64) (rc io.ReadCloser, err error) {
+	for i := 0; i < r.attempts; i++ {
+		rc, err = getRange(r.base, key, offset, length)
+		if err == nil || err == utahfs.ErrObjectNotFound {
+			return
+		}
+	}
+	return
+}
+
+// SetStream streams data to the base backend, retrying on failure. If r
+// doesn't implement io.Seeker, it can't be safely rewound after a partial
+// failed attempt, so only one attempt is made.
+func (r *retry) SetStream(key string, rdr io.Reader, size int64) (err error) {
+	seeker, canSeek := rdr.(io.Seeker)
+	for i := 0; i < r.attempts; i++ {
+		if i > 0 {
+			if !canSeek {
+				break
+			}
+			if _, err = seeker.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		err = setStream(r.base, key, rdr, size)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (c *cache) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	val, ok := c.cache.Get(key)
+	if c.collector != nil {
+		c.collector.IncCacheResult(ok)
+	}
+	if ok {
+		data := val.([]byte)
+		if rangeCovered(int64(len(data)), offset, length) {
+			return sliceRange(data, offset, length)
+		}
+	}
+	return getRange(c.base, key, offset, length)
+}
+
+// SetStream buffers r and writes it through Set, so the cached copy stays
+// consistent with the base backend.
+func (c *cache) SetStream(key string, r io.Reader, size int64) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+	return c.Set(key, data)
+}
+
+func (c *sizeCache) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	c.mu.Lock()
+	val, ok := c.lru.Get(key)
+	if c.collector != nil {
+		c.collector.IncCacheResult(ok)
+	}
+	c.mu.Unlock()
+	if ok {
+		data := val.([]byte)
+		if rangeCovered(int64(len(data)), offset, length) {
+			return sliceRange(data, offset, length)
+		}
+	}
+	return getRange(c.base, key, offset, length)
+}
+
+// SetStream buffers r and writes it through Set, so the cached copy stays
+// consistent with the base backend and counts against maxBytes.
+func (c *sizeCache) SetStream(key string, r io.Reader, size int64) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+	return c.Set(key, data)
+}
+
+func (r *backoffRetry) GetRangeCtx(ctx context.Context, key string, offset, length int64) (rc io.ReadCloser, err error) {
+	err = r.retry(ctx, func() error {
+		rc, err = getRange(r.base, key, offset, length)
+		return err
+	})
+	return
+}
+
+// SetStreamCtx streams data to the base backend with backoff between
+// retries. If rdr doesn't implement io.Seeker, it can't be safely rewound
+// after a partial failed attempt, so a failure there is surfaced directly
+// rather than retried.
+func (r *backoffRetry) SetStreamCtx(ctx context.Context, key string, rdr io.Reader, size int64) error {
+	seeker, canSeek := rdr.(io.Seeker)
+	start, attempt := time.Now(), 0
+	for {
+		err := setStream(r.base, key, rdr, size)
+		if !r.opts.isRetryable(err) {
+			return err
+		} else if r.opts.MaxElapsedTime > 0 && time.Since(start) >= r.opts.MaxElapsedTime {
+			return err
+		} else if !canSeek {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.opts.delay(attempt)):
+		}
+		attempt++
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *backoffRetry) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	return r.GetRangeCtx(context.Background(), key, offset, length)
+}
+
+func (r *backoffRetry) SetStream(key string, rdr io.Reader, size int64) error {
+	return r.SetStreamCtx(context.Background(), key, rdr, size)
+}